@@ -0,0 +1,141 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+// periph-transcript inspects and diffs conntest transcripts, the
+// line-delimited JSON files written by (*conntest.Record).MarshalTranscript
+// and read back by conntest.LoadPlayback.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/google/pio/conn/conntest"
+)
+
+func main() {
+	if err := mainImpl(); err != nil {
+		fmt.Fprintf(os.Stderr, "periph-transcript: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func mainImpl() error {
+	if len(os.Args) < 2 {
+		return usageErr()
+	}
+	switch os.Args[1] {
+	case "inspect":
+		return inspect(os.Args[2:])
+	case "diff":
+		return diff(os.Args[2:])
+	default:
+		return usageErr()
+	}
+}
+
+func usageErr() error {
+	return fmt.Errorf("usage: periph-transcript <inspect|diff> <file> [file2]")
+}
+
+// inspect prints a transcript's ops one per line, in a human-readable form.
+func inspect(args []string) error {
+	fs := flag.NewFlagSet("inspect", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: periph-transcript inspect <file>")
+	}
+	p, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	for i, op := range p.Ops {
+		printOp(os.Stdout, i, &op)
+	}
+	return nil
+}
+
+// diff prints, by index, the ops whose Write, Read or Tag differ between two
+// transcripts. Timestamp and MatcherKind are not compared.
+func diff(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("usage: periph-transcript diff <file1> <file2>")
+	}
+	a, err := loadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	b, err := loadFile(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	n := len(a.Ops)
+	if len(b.Ops) > n {
+		n = len(b.Ops)
+	}
+	diffs := 0
+	for i := 0; i < n; i++ {
+		var opA, opB *conntest.IO
+		if i < len(a.Ops) {
+			opA = &a.Ops[i]
+		}
+		if i < len(b.Ops) {
+			opB = &b.Ops[i]
+		}
+		if opsEqual(opA, opB) {
+			continue
+		}
+		diffs++
+		fmt.Printf("op %d differs:\n", i)
+		fmt.Printf("  - %s\n", formatOp(opA))
+		fmt.Printf("  + %s\n", formatOp(opB))
+	}
+	if diffs == 0 {
+		fmt.Println("no differences")
+	}
+	return nil
+}
+
+func loadFile(path string) (*conntest.Playback, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	p, err := conntest.LoadPlayback(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return p, nil
+}
+
+func opsEqual(a, b *conntest.IO) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return string(a.Write) == string(b.Write) && string(a.Read) == string(b.Read) && a.Tag == b.Tag
+}
+
+func formatOp(op *conntest.IO) string {
+	if op == nil {
+		return "<missing>"
+	}
+	return fmt.Sprintf("write=%#v read=%#v tag=%q", op.Write, op.Read, op.Tag)
+}
+
+func printOp(w io.Writer, i int, op *conntest.IO) {
+	tag := ""
+	if op.Tag != "" {
+		tag = fmt.Sprintf(" tag=%q", op.Tag)
+	}
+	fmt.Fprintf(w, "%4d: write=%#v read=%#v%s\n", i, op.Write, op.Read, tag)
+}
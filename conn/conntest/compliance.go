@@ -0,0 +1,230 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/pio/conn"
+)
+
+// MakePipe creates a connected pair of conn.Conn endpoints, plus a stop func
+// to release any resource associated with them.
+//
+// It is modeled after golang.org/x/net/nettest.MakePipe: c1 and c2 must be
+// two live endpoints, wired together so that TestConn can exercise both
+// directions. They need not be of the same conn.Conn implementation.
+type MakePipe func() (c1, c2 conn.Conn, stop func(), err error)
+
+// TestConn tests that an implementation of conn.Conn behaves as the
+// interface requires.
+//
+// TestConn is modeled after golang.org/x/net/nettest.TestConn: it is meant
+// to be called from the TestXxx function of out-of-tree driver fakes to
+// confirm they are safe to use as a conn.Conn. This package's own tests run
+// it against Record and Pipe; Playback is not run through it.
+func TestConn(t *testing.T, mp MakePipe) {
+	t.Run("BasicIO", func(t *testing.T) { testBasicIO(t, mp) })
+	t.Run("PingPong", func(t *testing.T) { testPingPong(t, mp) })
+	t.Run("RacyTx", func(t *testing.T) { testRacyTx(t, mp) })
+	t.Run("EmptyTx", func(t *testing.T) { testEmptyTx(t, mp) })
+	t.Run("ZeroLenRead", func(t *testing.T) { testZeroLenRead(t, mp) })
+	t.Run("ZeroLenWrite", func(t *testing.T) { testZeroLenWrite(t, mp) })
+}
+
+func newPipe(t *testing.T, mp MakePipe) (conn.Conn, conn.Conn, func()) {
+	c1, c2, stop, err := mp()
+	if err != nil {
+		t.Fatalf("conntest: MakePipe failed: %v", err)
+	}
+	if stop == nil {
+		stop = func() {}
+	}
+	return c1, c2, stop
+}
+
+// testBasicIO writes a payload on one end and asserts it is read back
+// verbatim on the other end.
+func testBasicIO(t *testing.T, mp MakePipe) {
+	c1, c2, stop := newPipe(t, mp)
+	defer stop()
+
+	want := []byte("conntest: basic I/O payload")
+	got := make([]byte, len(want))
+	done := make(chan error, 1)
+	go func() {
+		done <- c2.Tx(nil, got)
+	}()
+	if err := c1.Tx(want, nil); err != nil {
+		t.Fatalf("c1.Tx() failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("c2.Tx() failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("c2 read %#v, want %#v", got, want)
+	}
+}
+
+// testPingPong alternates short Tx rounds in both directions.
+func testPingPong(t *testing.T, mp MakePipe) {
+	c1, c2, stop := newPipe(t, mp)
+	defer stop()
+
+	for i := 0; i < 10; i++ {
+		ping := []byte{byte(i), byte(i + 1)}
+		pong := []byte{byte(255 - i), byte(254 - i)}
+		gotPing := make([]byte, len(ping))
+		gotPong := make([]byte, len(pong))
+		done := make(chan error, 1)
+		go func() {
+			done <- c2.Tx(pong, gotPing)
+		}()
+		if err := c1.Tx(ping, gotPong); err != nil {
+			t.Fatalf("round %d: c1.Tx() failed: %v", i, err)
+		}
+		if err := <-done; err != nil {
+			t.Fatalf("round %d: c2.Tx() failed: %v", i, err)
+		}
+		if !bytes.Equal(gotPing, ping) {
+			t.Fatalf("round %d: c2 read %#v, want %#v", i, gotPing, ping)
+		}
+		if !bytes.Equal(gotPong, pong) {
+			t.Fatalf("round %d: c1 read %#v, want %#v", i, gotPong, pong)
+		}
+	}
+}
+
+// testRacyTx fires many concurrent Tx calls from both ends with disjoint
+// payloads and asserts that no data is corrupted in transit: every frame a
+// side receives must be one of the peer's intact, well-formed frames, and
+// every frame the peer sent must show up exactly once, with no drops or
+// duplicates. Which goroutine happens to receive which frame is racy by
+// design, so this does not assert a 1:1 index pairing between senders.
+func testRacyTx(t *testing.T, mp MakePipe) {
+	c1, c2, stop := newPipe(t, mp)
+	defer stop()
+
+	const n = 50
+	var wg sync.WaitGroup
+	wg.Add(2 * n)
+	errs := make(chan error, 2*n)
+
+	var mu sync.Mutex
+	seenByC1 := make(map[string]bool, n)
+	seenByC2 := make(map[string]bool, n)
+
+	for i := 0; i < n; i++ {
+		i := i
+		go func() {
+			defer wg.Done()
+			w := []byte(fmt.Sprintf("c1-frame-%03d", i))
+			r := make([]byte, len(fmt.Sprintf("c2-frame-%03d", i)))
+			if err := c1.Tx(w, r); err != nil {
+				errs <- err
+				return
+			}
+			got := string(r)
+			if !strings.HasPrefix(got, "c2-frame-") {
+				errs <- fmt.Errorf("c1 round %d: corrupted read %q", i, got)
+				return
+			}
+			mu.Lock()
+			seenByC1[got] = true
+			mu.Unlock()
+		}()
+		go func() {
+			defer wg.Done()
+			w := []byte(fmt.Sprintf("c2-frame-%03d", i))
+			r := make([]byte, len(fmt.Sprintf("c1-frame-%03d", i)))
+			if err := c2.Tx(w, r); err != nil {
+				errs <- err
+				return
+			}
+			got := string(r)
+			if !strings.HasPrefix(got, "c1-frame-") {
+				errs <- fmt.Errorf("c2 round %d: corrupted read %q", i, got)
+				return
+			}
+			mu.Lock()
+			seenByC2[got] = true
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if len(seenByC1) != n {
+		t.Errorf("c1 saw %d distinct c2 frames, want %d (dropped or duplicated)", len(seenByC1), n)
+	}
+	if len(seenByC2) != n {
+		t.Errorf("c2 saw %d distinct c1 frames, want %d (dropped or duplicated)", len(seenByC2), n)
+	}
+}
+
+// testEmptyTx asserts that a Tx with no write and no read is a no-op that
+// completes without error.
+func testEmptyTx(t *testing.T, mp MakePipe) {
+	c1, c2, stop := newPipe(t, mp)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c2.Tx(nil, nil)
+	}()
+	if err := c1.Tx(nil, nil); err != nil {
+		t.Fatalf("c1.Tx(nil, nil) failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("c2.Tx(nil, nil) failed: %v", err)
+	}
+}
+
+// testZeroLenRead asserts that a Tx with a zero-length (but non-nil) read
+// buffer completes without error, as one with no read buffer does.
+func testZeroLenRead(t *testing.T, mp MakePipe) {
+	c1, c2, stop := newPipe(t, mp)
+	defer stop()
+
+	want := []byte("conntest: zero-len read")
+	r := []byte{}
+	done := make(chan error, 1)
+	go func() {
+		done <- c2.Tx(nil, nil)
+	}()
+	if err := c1.Tx(want, r); err != nil {
+		t.Fatalf("c1.Tx() failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("c2.Tx() failed: %v", err)
+	}
+}
+
+// testZeroLenWrite asserts that a Tx with a zero-length (but non-nil) write
+// buffer completes without error, as one with no write buffer does.
+func testZeroLenWrite(t *testing.T, mp MakePipe) {
+	c1, c2, stop := newPipe(t, mp)
+	defer stop()
+
+	want := []byte("conntest: zero-len write")
+	got := make([]byte, len(want))
+	done := make(chan error, 1)
+	go func() {
+		done <- c2.Tx(nil, got)
+	}()
+	if err := c1.Tx([]byte{}, nil); err != nil {
+		t.Fatalf("c1.Tx() failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("c2.Tx() failed: %v", err)
+	}
+}
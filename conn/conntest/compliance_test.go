@@ -0,0 +1,101 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/pio/conn"
+)
+
+func recordPipeMakePipe() (conn.Conn, conn.Conn, func(), error) {
+	a, b, stop := Pipe()
+	rec := &Record{Conn: a}
+	return rec, b, stop, nil
+}
+
+func TestRecordCompliance(t *testing.T) {
+	TestConn(t, recordPipeMakePipe)
+}
+
+// TestRecordRacyOps drives racy, concurrent Tx calls with disjoint write
+// payloads directly against a Record, then asserts that Record.Ops ends
+// up holding exactly the set of frames submitted through it: no op
+// dropped, duplicated, or corrupted by concurrent access to Record.Ops.
+func TestRecordRacyOps(t *testing.T) {
+	a, b, stop := Pipe()
+	defer stop()
+	rec := &Record{Conn: a}
+
+	const n = 50
+	want := make(map[string]bool, n)
+	var wg sync.WaitGroup
+	wg.Add(2 * n)
+	for i := 0; i < n; i++ {
+		frame := fmt.Sprintf("frame-%03d", i)
+		want[frame] = true
+		go func() {
+			defer wg.Done()
+			if err := rec.Tx([]byte(frame), nil); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := b.Tx(nil, nil); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := make(map[string]bool, len(rec.Ops))
+	for _, op := range rec.Ops {
+		got[string(op.Write)] = true
+	}
+	if len(rec.Ops) != len(want) {
+		t.Fatalf("recorded %d ops, want %d", len(rec.Ops), len(want))
+	}
+	for frame := range want {
+		if !got[frame] {
+			t.Errorf("recorded ops missing submitted frame %q", frame)
+		}
+	}
+}
+
+// TestPlaybackSequential exercises Playback's own contract directly: it can
+// only replay a fixed, ordered transcript, so it cannot stand in as a
+// generic MakePipe peer the way Record (backed by a live conn.Conn) can.
+func TestPlaybackSequential(t *testing.T) {
+	p := &Playback{
+		Ops: []IO{
+			{Write: []byte("ping"), Read: []byte("pong")},
+			{Write: []byte("foo"), Read: []byte("bar")},
+		},
+	}
+	got := make([]byte, 4)
+	if err := p.Tx([]byte("ping"), got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte("pong")) {
+		t.Fatalf("got %q, want %q", got, "pong")
+	}
+	got = make([]byte, 3)
+	if err := p.Tx([]byte("foo"), got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte("bar")) {
+		t.Fatalf("got %q, want %q", got, "bar")
+	}
+	if len(p.Ops) != 0 {
+		t.Fatalf("expected all ops consumed, %d remain", len(p.Ops))
+	}
+	if err := p.Tx([]byte("extra"), nil); err == nil {
+		t.Fatal("expected error on unexpected Tx()")
+	}
+}
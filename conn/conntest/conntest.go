@@ -7,10 +7,11 @@ package conntest
 
 import (
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
 	"io"
 	"sync"
+	"time"
 
 	"github.com/google/pio/conn"
 )
@@ -34,17 +35,37 @@ func (r *RecordRaw) Write(b []byte) (int, error) {
 
 // Tx implements conn.Conn.
 func (r *RecordRaw) Tx(w, read []byte) error {
-	if len(read) != 0 {
-		return errors.New("not implemented")
-	}
-	_, err := r.Write(w)
-	return err
+	return r.TxContext(context.Background(), w, read)
 }
 
 // IO registers the I/O that happened on either a real or fake connection.
 type IO struct {
 	Write []byte
 	Read  []byte
+
+	// Matcher overrides how Playback decides this op matches a Tx write
+	// payload. When nil, the default is an exact bytes.Equal on Write.
+	Matcher Matcher
+	// Responder overrides how Playback generates the Tx read payload for
+	// this op. When nil, the default is to copy Read, erroring if its
+	// length doesn't match the caller's read buffer.
+	Responder Responder
+	// Delay simulates the latency of this op. TxContext returns
+	// context.DeadlineExceeded (or the context's error) if ctx fires before
+	// Delay elapses; plain Tx always waits it out.
+	Delay time.Duration
+
+	// Tag is a free-form label for this op, surfaced in Playback error
+	// messages and preserved across MarshalTranscript/LoadPlayback so a
+	// failure can be traced back to a specific line in a testdata file.
+	Tag string
+	// MatcherKind is a free-form, informational description of how Matcher
+	// was constructed (e.g. "exact", "prefix", "mask"). It has no effect on
+	// matching; it exists so a transcript stays self-documenting once
+	// written to disk, where Matcher itself can't be serialized.
+	MatcherKind string
+	// Timestamp records when this op was captured, if known.
+	Timestamp time.Time
 }
 
 // Record implements conn.Conn that records everything written to it.
@@ -70,27 +91,22 @@ func (r *Record) Write(d []byte) (int, error) {
 
 // Tx implements conn.Conn.
 func (r *Record) Tx(w, read []byte) error {
-	r.Lock.Lock()
-	defer r.Lock.Unlock()
-	if r.Conn == nil {
-		if len(read) != 0 {
-			return errors.New("read unsupported when no bus is connected")
-		}
-	} else {
-		if err := r.Conn.Tx(w, read); err != nil {
-			return err
-		}
-	}
-	io := IO{Write: make([]byte, len(w))}
-	if len(read) != 0 {
-		io.Read = make([]byte, len(read))
-	}
-	copy(io.Write, w)
-	copy(io.Read, read)
-	r.Ops = append(r.Ops, io)
-	return nil
+	return r.TxContext(context.Background(), w, read)
 }
 
+// Ordering controls how Playback picks which queued IO op a Tx must match.
+type Ordering int
+
+const (
+	// StrictOrdering requires each Tx to match the oldest unconsumed op, in
+	// the order they were queued. This is the zero value, so existing
+	// Playback users keep the original strict-replay behavior.
+	StrictOrdering Ordering = iota
+	// AnyOrder lets a Tx match any unconsumed op, useful for testing
+	// concurrent driver code paths that don't issue Tx in a fixed order.
+	AnyOrder
+)
+
 // Playback implements conn.Conn and plays back a recorded I/O flow.
 //
 // While "replay" type of unit tests are of limited value, they still present
@@ -98,6 +114,9 @@ func (r *Record) Tx(w, read []byte) error {
 type Playback struct {
 	Lock sync.Mutex
 	Ops  []IO
+	// Ordering selects how a Tx write is matched against Ops. Defaults to
+	// StrictOrdering.
+	Ordering Ordering
 }
 
 func (p *Playback) String() string {
@@ -114,23 +133,44 @@ func (p *Playback) Write(d []byte) (int, error) {
 
 // Tx implements conn.Conn.
 func (p *Playback) Tx(w, r []byte) error {
-	p.Lock.Lock()
-	defer p.Lock.Unlock()
-	if len(p.Ops) == 0 {
-		// log.Fatal() ?
-		return errors.New("unexpected Tx()")
+	return p.TxContext(context.Background(), w, r)
+}
+
+// match reports whether w satisfies op, using op.Matcher when set and an
+// exact bytes.Equal on op.Write otherwise.
+func match(op *IO, w []byte) bool {
+	if op.Matcher != nil {
+		return op.Matcher.Match(w)
 	}
-	if !bytes.Equal(p.Ops[0].Write, w) {
-		return fmt.Errorf("unexpected write %#v != %#v", w, p.Ops[0].Write)
+	return bytes.Equal(op.Write, w)
+}
+
+// respond fills r for a Tx matching op, using op.Responder when set and a
+// copy of op.Read otherwise. idx is the op's position in Ops, used only to
+// label errors.
+func respond(idx int, op *IO, w, r []byte) error {
+	if op.Responder != nil {
+		if err := op.Responder.Respond(w, r); err != nil {
+			return fmt.Errorf("%s: %v", opLabel(idx, op), err)
+		}
+		return nil
 	}
-	if len(p.Ops[0].Read) != len(r) {
-		return fmt.Errorf("unexpected read buffer length %d != %d", len(r), len(p.Ops[0].Read))
+	if len(op.Read) != len(r) {
+		return fmt.Errorf("%s: unexpected read buffer length %d != %d", opLabel(idx, op), len(r), len(op.Read))
 	}
-	copy(r, p.Ops[0].Read)
-	p.Ops = p.Ops[1:]
+	copy(r, op.Read)
 	return nil
 }
 
+// opLabel identifies an op in error messages, citing its Tag when set so a
+// failure can be traced back to a specific line in a loaded transcript.
+func opLabel(idx int, op *IO) string {
+	if op.Tag != "" {
+		return fmt.Sprintf("op %d (tag %q)", idx, op.Tag)
+	}
+	return fmt.Sprintf("op %d", idx)
+}
+
 var _ conn.Conn = &RecordRaw{}
 var _ conn.Conn = &Record{}
-var _ conn.Conn = &Playback{}
\ No newline at end of file
+var _ conn.Conn = &Playback{}
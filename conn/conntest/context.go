@@ -0,0 +1,121 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ConnContext is implemented by conn.Conn implementations, including
+// RecordRaw, Record and Playback, that support a context-aware Tx.
+//
+// Tx is equivalent to TxContext(context.Background(), w, r).
+type ConnContext interface {
+	TxContext(ctx context.Context, w, r []byte) error
+}
+
+// TxContext is like Tx but returns ctx.Err() without writing if ctx is
+// already canceled or past its deadline. Once the underlying Write has
+// started, it is not interrupted by ctx.
+func (r *RecordRaw) TxContext(ctx context.Context, w, read []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(read) != 0 {
+		return errors.New("not implemented")
+	}
+	_, err := r.Write(w)
+	return err
+}
+
+// TxContext is like Tx but forwards ctx to the wrapped Conn when it
+// implements ConnContext, and returns ctx.Err() immediately if ctx is
+// already done.
+func (r *Record) TxContext(ctx context.Context, w, read []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	if r.Conn == nil {
+		if len(read) != 0 {
+			return errors.New("read unsupported when no bus is connected")
+		}
+	} else if cc, ok := r.Conn.(ConnContext); ok {
+		if err := cc.TxContext(ctx, w, read); err != nil {
+			return err
+		}
+	} else if err := r.Conn.Tx(w, read); err != nil {
+		return err
+	}
+	io := IO{Write: make([]byte, len(w))}
+	if len(read) != 0 {
+		io.Read = make([]byte, len(read))
+	}
+	copy(io.Write, w)
+	copy(io.Read, read)
+	r.Ops = append(r.Ops, io)
+	return nil
+}
+
+// TxContext is like Tx but simulates the matched op's Delay and returns
+// ctx.Err() if ctx is canceled or its deadline elapses before Delay has
+// fully elapsed.
+//
+// The matched op is claimed (removed from Ops) and the lock released before
+// waiting out Delay, so a concurrent Tx on the same Playback can match and
+// wait on a different op in parallel instead of queuing behind this one for
+// the full simulated latency.
+func (p *Playback) TxContext(ctx context.Context, w, r []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	p.Lock.Lock()
+	if len(p.Ops) == 0 {
+		p.Lock.Unlock()
+		// log.Fatal() ?
+		return errors.New("unexpected Tx()")
+	}
+	idx := 0
+	if p.Ordering == AnyOrder {
+		idx = -1
+		for i := range p.Ops {
+			if match(&p.Ops[i], w) {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			n := len(p.Ops)
+			p.Lock.Unlock()
+			return fmt.Errorf("no queued op (of %d) matches write %#v", n, w)
+		}
+	} else if !match(&p.Ops[0], w) {
+		op0 := p.Ops[0]
+		p.Lock.Unlock()
+		return fmt.Errorf("%s: unexpected write %#v != %#v", opLabel(0, &op0), w, op0.Write)
+	}
+	op := p.Ops[idx]
+	p.Ops = append(p.Ops[:idx], p.Ops[idx+1:]...)
+	p.Lock.Unlock()
+
+	if op.Delay > 0 {
+		timer := time.NewTimer(op.Delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return respond(idx, &op, w, r)
+}
+
+var _ ConnContext = &RecordRaw{}
+var _ ConnContext = &Record{}
+var _ ConnContext = &Playback{}
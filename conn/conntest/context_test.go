@@ -0,0 +1,93 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPlaybackTxContextExpired(t *testing.T) {
+	p := &Playback{Ops: []IO{{Write: []byte("a"), Read: []byte("1")}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.TxContext(ctx, []byte("a"), make([]byte, 1)); err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if len(p.Ops) != 1 {
+		t.Fatalf("expected the op to remain queued, got %d left", len(p.Ops))
+	}
+}
+
+func TestPlaybackTxContextCancelMidDelay(t *testing.T) {
+	p := &Playback{Ops: []IO{{Write: []byte("a"), Read: []byte("1"), Delay: time.Hour}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- p.TxContext(ctx, []byte("a"), make([]byte, 1))
+	}()
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("got %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("TxContext did not return after cancellation")
+	}
+}
+
+func TestPlaybackTxContextDelayElapses(t *testing.T) {
+	p := &Playback{Ops: []IO{{Write: []byte("a"), Read: []byte("1"), Delay: 10 * time.Millisecond}}}
+	got := make([]byte, 1)
+	if err := p.TxContext(context.Background(), []byte("a"), got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != '1' {
+		t.Fatalf("got %q", got)
+	}
+}
+
+// TestPlaybackTxContextConcurrentDelaysDontSerialize asserts that one Tx
+// blocked on a long Delay doesn't hold the Playback's lock for the full
+// wait: a second, unrelated Tx on a different queued op must be able to
+// complete in the meantime instead of queuing behind it.
+func TestPlaybackTxContextConcurrentDelaysDontSerialize(t *testing.T) {
+	p := &Playback{
+		Ordering: AnyOrder,
+		Ops: []IO{
+			{Write: []byte("slow"), Read: []byte("1"), Delay: time.Hour},
+			{Write: []byte("fast"), Read: []byte("2")},
+		},
+	}
+	slowCtx, cancelSlow := context.WithCancel(context.Background())
+	defer cancelSlow()
+	slowDone := make(chan error, 1)
+	go func() {
+		slowDone <- p.TxContext(slowCtx, []byte("slow"), make([]byte, 1))
+	}()
+
+	fastDone := make(chan error, 1)
+	go func() {
+		fastDone <- p.TxContext(context.Background(), []byte("fast"), make([]byte, 1))
+	}()
+
+	select {
+	case err := <-fastDone:
+		if err != nil {
+			t.Fatalf("fast Tx failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fast Tx blocked behind the slow Tx's Delay")
+	}
+
+	select {
+	case <-slowDone:
+		t.Fatal("slow Tx should still be waiting out its hour-long Delay")
+	default:
+	}
+}
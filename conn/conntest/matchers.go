@@ -0,0 +1,87 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Matcher decides whether a Tx write payload satisfies a queued IO op.
+//
+// Playback falls back to an exact bytes.Equal on IO.Write when an op's
+// Matcher is nil.
+type Matcher interface {
+	Match(w []byte) bool
+}
+
+// MatcherFunc adapts a function to a Matcher.
+type MatcherFunc func(w []byte) bool
+
+// Match implements Matcher.
+func (f MatcherFunc) Match(w []byte) bool {
+	return f(w)
+}
+
+// PrefixMatcher matches any write starting with Prefix, useful when a
+// driver embeds varying data (timestamps, sequence numbers) after a fixed
+// command or register header.
+type PrefixMatcher struct {
+	Prefix []byte
+}
+
+// Match implements Matcher.
+func (m PrefixMatcher) Match(w []byte) bool {
+	return bytes.HasPrefix(w, m.Prefix)
+}
+
+// MaskMatcher matches a write ignoring the bits cleared in Mask, e.g. to
+// match a register address while ignoring payload bits that vary between
+// runs (timestamps, counters, CRCs).
+type MaskMatcher struct {
+	Mask  []byte
+	Value []byte
+}
+
+// Match implements Matcher.
+func (m MaskMatcher) Match(w []byte) bool {
+	if len(w) != len(m.Mask) || len(w) != len(m.Value) {
+		return false
+	}
+	for i, b := range w {
+		if b&m.Mask[i] != m.Value[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Responder generates the Tx read payload for a queued IO op that matched.
+//
+// Playback falls back to copying IO.Read when an op's Responder is nil.
+type Responder interface {
+	Respond(w, r []byte) error
+}
+
+// ResponderFunc adapts a function to a Responder.
+type ResponderFunc func(w, r []byte) error
+
+// Respond implements Responder.
+func (f ResponderFunc) Respond(w, r []byte) error {
+	return f(w, r)
+}
+
+// EchoResponder copies the write payload back as the read payload, useful
+// for drivers under test that just bounce data off a peripheral.
+type EchoResponder struct{}
+
+// Respond implements Responder.
+func (EchoResponder) Respond(w, r []byte) error {
+	if len(r) != len(w) {
+		return fmt.Errorf("conntest: echo: read buffer length %d != write length %d", len(r), len(w))
+	}
+	copy(r, w)
+	return nil
+}
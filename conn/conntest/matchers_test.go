@@ -0,0 +1,79 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPlaybackPrefixMatcher(t *testing.T) {
+	p := &Playback{
+		Ops: []IO{
+			{Matcher: PrefixMatcher{Prefix: []byte{0x10}}, Responder: EchoResponder{}},
+		},
+	}
+	got := make([]byte, 3)
+	if err := p.Tx([]byte{0x10, 0xaa, 0xbb}, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte{0x10, 0xaa, 0xbb}) {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestPlaybackMaskMatcher(t *testing.T) {
+	p := &Playback{
+		Ops: []IO{
+			{
+				Matcher:   MaskMatcher{Mask: []byte{0xff, 0x00}, Value: []byte{0x42, 0x00}},
+				Responder: ResponderFunc(func(w, r []byte) error { copy(r, []byte{0x55}); return nil }),
+			},
+		},
+	}
+	got := make([]byte, 1)
+	if err := p.Tx([]byte{0x42, 0x99}, got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != 0x55 {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestPlaybackAnyOrder(t *testing.T) {
+	p := &Playback{
+		Ordering: AnyOrder,
+		Ops: []IO{
+			{Write: []byte("a"), Read: []byte("1")},
+			{Write: []byte("b"), Read: []byte("2")},
+		},
+	}
+	got := make([]byte, 1)
+	if err := p.Tx([]byte("b"), got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != '2' {
+		t.Fatalf("got %q", got)
+	}
+	if err := p.Tx([]byte("a"), got); err != nil {
+		t.Fatal(err)
+	}
+	if got[0] != '1' {
+		t.Fatalf("got %q", got)
+	}
+	if len(p.Ops) != 0 {
+		t.Fatalf("expected all ops consumed, %d remain", len(p.Ops))
+	}
+}
+
+func TestPlaybackAnyOrderNoMatch(t *testing.T) {
+	p := &Playback{
+		Ordering: AnyOrder,
+		Ops:      []IO{{Write: []byte("a"), Read: []byte("1")}},
+	}
+	if err := p.Tx([]byte("z"), make([]byte, 1)); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"io"
+	"sync"
+
+	"github.com/google/pio/conn"
+)
+
+// Pipe returns two conn.Conn endpoints wired together: whatever one side
+// writes via Tx(w, r) is delivered as the read result of the other side's
+// next Tx, and the other side's write is fed back as the first side's read
+// result.
+//
+// Unlike Record and Playback, which replay a fixed transcript, a Pipe lets
+// one endpoint stand in for a peripheral that responds to register reads
+// dynamically while the other endpoint is handed to the driver under test.
+// This is the loopback used by MakePipe implementations passed to TestConn.
+func Pipe() (c1, c2 conn.Conn, stop func()) {
+	// Buffered so that a side's write doesn't have to wait for the peer to
+	// already be receiving: both sides can issue Tx concurrently without
+	// either blocked send starving the other's.
+	a2b := make(chan []byte, 1)
+	b2a := make(chan []byte, 1)
+	closed1 := make(chan struct{})
+	closed2 := make(chan struct{})
+	p1 := &pipeConn{name: "pipe0", out: a2b, in: b2a, selfClosed: closed1, peerClosed: closed2}
+	p2 := &pipeConn{name: "pipe1", out: b2a, in: a2b, selfClosed: closed2, peerClosed: closed1}
+	stop = func() {
+		p1.Close()
+		p2.Close()
+	}
+	return p1, p2, stop
+}
+
+// LoopbackPipe adapts Pipe to the MakePipe signature expected by TestConn.
+func LoopbackPipe() (c1, c2 conn.Conn, stop func(), err error) {
+	c1, c2, stop = Pipe()
+	return c1, c2, stop, nil
+}
+
+// pipeConn implements conn.Conn on one end of a Pipe.
+type pipeConn struct {
+	name string
+	out  chan<- []byte
+	in   <-chan []byte
+
+	closeOnce  sync.Once
+	selfClosed chan struct{}
+	peerClosed chan struct{}
+}
+
+func (p *pipeConn) String() string {
+	return p.name
+}
+
+// Write implements conn.Conn.
+func (p *pipeConn) Write(d []byte) (int, error) {
+	if err := p.Tx(d, nil); err != nil {
+		return 0, err
+	}
+	return len(d), nil
+}
+
+// Tx implements conn.Conn.
+func (p *pipeConn) Tx(w, r []byte) error {
+	select {
+	case <-p.selfClosed:
+		return io.ErrClosedPipe
+	case <-p.peerClosed:
+		return io.ErrClosedPipe
+	default:
+	}
+	wc := make([]byte, len(w))
+	copy(wc, w)
+	select {
+	case p.out <- wc:
+	case <-p.selfClosed:
+		return io.ErrClosedPipe
+	case <-p.peerClosed:
+		return io.ErrClosedPipe
+	}
+	select {
+	case in := <-p.in:
+		if len(r) != 0 {
+			copy(r, in)
+		}
+	case <-p.selfClosed:
+		return io.ErrClosedPipe
+	case <-p.peerClosed:
+		return io.ErrClosedPipe
+	}
+	return nil
+}
+
+// Close half-closes this endpoint: any Tx blocked on it, or issued after
+// this call, returns io.ErrClosedPipe instead of deadlocking. It does not
+// call the peer's Close, but the peer's Tx calls also return
+// io.ErrClosedPipe from then on.
+func (p *pipeConn) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.selfClosed)
+	})
+	return nil
+}
+
+var _ conn.Conn = &pipeConn{}
+var _ io.Closer = &pipeConn{}
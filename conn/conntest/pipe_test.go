@@ -0,0 +1,85 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPipeCompliance(t *testing.T) {
+	TestConn(t, LoopbackPipe)
+}
+
+// TestPipeConcurrentBidirectional calls Tx on both ends at the same time,
+// each blocking on its own read until the peer's write arrives. Both sides
+// issuing Tx before either reaches its read step must not deadlock.
+func TestPipeConcurrentBidirectional(t *testing.T) {
+	c1, c2, stop := Pipe()
+	defer stop()
+
+	want1 := []byte("from c1")
+	want2 := []byte("from c2")
+	got1 := make([]byte, len(want2))
+	got2 := make([]byte, len(want1))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	var err1, err2 error
+	go func() {
+		defer wg.Done()
+		err1 = c1.Tx(want1, got1)
+	}()
+	go func() {
+		defer wg.Done()
+		err2 = c2.Tx(want2, got2)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("concurrent Tx from both ends deadlocked")
+	}
+
+	if err1 != nil {
+		t.Fatalf("c1.Tx() failed: %v", err1)
+	}
+	if err2 != nil {
+		t.Fatalf("c2.Tx() failed: %v", err2)
+	}
+	if !bytes.Equal(got1, want2) {
+		t.Fatalf("c1 read %#v, want %#v", got1, want2)
+	}
+	if !bytes.Equal(got2, want1) {
+		t.Fatalf("c2 read %#v, want %#v", got2, want1)
+	}
+}
+
+func TestPipeClose(t *testing.T) {
+	c1, c2, stop := Pipe()
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c1.Tx(nil, make([]byte, 1))
+	}()
+	// c1 is now blocked waiting for a peer write; stop() must unblock it
+	// with an error instead of deadlocking.
+	stop()
+	if err := <-done; err != io.ErrClosedPipe {
+		t.Fatalf("got %v, want io.ErrClosedPipe", err)
+	}
+	if err := c2.Tx(nil, nil); err != io.ErrClosedPipe {
+		t.Fatalf("got %v, want io.ErrClosedPipe", err)
+	}
+}
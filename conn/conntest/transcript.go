@@ -0,0 +1,81 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// transcriptOp is the on-disk, line-delimited JSON representation of one IO
+// op. encoding/json base64-encodes []byte fields by default, so the format
+// stays a single diffable line per op.
+type transcriptOp struct {
+	Write       []byte `json:"write,omitempty"`
+	Read        []byte `json:"read,omitempty"`
+	Tag         string `json:"tag,omitempty"`
+	MatcherKind string `json:"matcher_kind,omitempty"`
+
+	// Timestamp is a pointer, not time.Time, because omitempty has no
+	// effect on a non-pointer struct field: every line would otherwise get
+	// a bogus "0001-01-01T00:00:00Z" even when no timestamp was recorded.
+	Timestamp *time.Time `json:"timestamp,omitempty"`
+}
+
+// MarshalTranscript writes r's recorded Ops as line-delimited JSON, one op
+// per line, suitable for committing as a testdata file and reloading with
+// LoadPlayback to replay the same flow without the hardware that produced
+// it.
+func (r *Record) MarshalTranscript(w io.Writer) error {
+	r.Lock.Lock()
+	defer r.Lock.Unlock()
+	enc := json.NewEncoder(w)
+	for i, op := range r.Ops {
+		t := transcriptOp{
+			Write:       op.Write,
+			Read:        op.Read,
+			Tag:         op.Tag,
+			MatcherKind: op.MatcherKind,
+		}
+		if !op.Timestamp.IsZero() {
+			ts := op.Timestamp
+			t.Timestamp = &ts
+		}
+		if err := enc.Encode(&t); err != nil {
+			return fmt.Errorf("conntest: marshal op %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// LoadPlayback reads a transcript written by MarshalTranscript and returns a
+// Playback preloaded with its Ops in StrictOrdering, ready to drive replay
+// tests without the bus that produced the transcript.
+func LoadPlayback(r io.Reader) (*Playback, error) {
+	var ops []IO
+	dec := json.NewDecoder(r)
+	for i := 0; ; i++ {
+		var t transcriptOp
+		if err := dec.Decode(&t); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("conntest: load transcript: op %d: %v", i, err)
+		}
+		op := IO{
+			Write:       t.Write,
+			Read:        t.Read,
+			Tag:         t.Tag,
+			MatcherKind: t.MatcherKind,
+		}
+		if t.Timestamp != nil {
+			op.Timestamp = *t.Timestamp
+		}
+		ops = append(ops, op)
+	}
+	return &Playback{Ops: ops}, nil
+}
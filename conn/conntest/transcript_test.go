@@ -0,0 +1,78 @@
+// Copyright 2016 The PIO Authors. All rights reserved.
+// Use of this source code is governed under the Apache License, Version 2.0
+// that can be found in the LICENSE file.
+
+package conntest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTranscriptRoundTrip(t *testing.T) {
+	rec := &Record{
+		Ops: []IO{
+			{Write: []byte("ping"), Read: []byte("pong"), Tag: "handshake"},
+			{Write: []byte{0x10, 0x00}, Read: []byte{0x42}, Tag: "read reg 0x10"},
+		},
+	}
+	var buf bytes.Buffer
+	if err := rec.MarshalTranscript(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(buf.String(), "\n") != 2 {
+		t.Fatalf("expected one line per op, got:\n%s", buf.String())
+	}
+	if strings.Contains(buf.String(), "timestamp") {
+		t.Fatalf("ops with no recorded Timestamp shouldn't emit the field:\n%s", buf.String())
+	}
+
+	p, err := LoadPlayback(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Ops) != 2 {
+		t.Fatalf("got %d ops, want 2", len(p.Ops))
+	}
+
+	got := make([]byte, 4)
+	if err := p.Tx([]byte("ping"), got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, []byte("pong")) {
+		t.Fatalf("got %q, want %q", got, "pong")
+	}
+}
+
+func TestTranscriptTimestampRoundTrip(t *testing.T) {
+	ts := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	rec := &Record{Ops: []IO{{Write: []byte("a"), Read: []byte("1"), Timestamp: ts}}}
+	var buf bytes.Buffer
+	if err := rec.MarshalTranscript(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "timestamp") {
+		t.Fatalf("expected a recorded Timestamp to be emitted:\n%s", buf.String())
+	}
+
+	p, err := LoadPlayback(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Ops[0].Timestamp.Equal(ts) {
+		t.Fatalf("got %v, want %v", p.Ops[0].Timestamp, ts)
+	}
+}
+
+func TestTranscriptErrorCitesTag(t *testing.T) {
+	p := &Playback{Ops: []IO{{Write: []byte("a"), Read: []byte("1"), Tag: "testdata/foo.json:1"}}}
+	err := p.Tx([]byte("wrong"), make([]byte, 1))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(err.Error(), "testdata/foo.json:1") {
+		t.Fatalf("error %q doesn't cite the op's tag", err)
+	}
+}